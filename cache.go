@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// responseRecorder captures a handler's response so it can be cached and
+// replayed for later callers with the same cache key.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header), status: http.StatusOK}
+}
+
+func (rec *responseRecorder) Header() http.Header { return rec.header }
+
+func (rec *responseRecorder) Write(p []byte) (int, error) { return rec.body.Write(p) }
+
+func (rec *responseRecorder) WriteHeader(status int) { rec.status = status }
+
+// cacheEntry is a recorded response, valid until expires.
+type cacheEntry struct {
+	header  http.Header
+	status  int
+	body    []byte
+	expires time.Time
+}
+
+// cachingHandler caches the marshalled body of next keyed by (method,
+// accept header, query params) for a TTL, collapsing concurrent requests
+// for the same key into a single call to next via singleflight - readAllProcs
+// walks /proc on every call, which is too expensive to repeat for every
+// dashboard poller hitting the same endpoint at once.
+type cachingHandler struct {
+	next http.Handler
+	ttl  time.Duration
+
+	group singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+
+	stop chan struct{}
+}
+
+func newCachingHandler(next http.Handler, ttl time.Duration) *cachingHandler {
+	c := &cachingHandler{next: next, ttl: ttl, entries: make(map[string]*cacheEntry), stop: make(chan struct{})}
+	go c.sweepExpired()
+	return c
+}
+
+// sweepExpired periodically evicts expired entries so keys that are never
+// requested again (e.g. a one-off pid= filter) don't linger in the map
+// forever; entries that are requested again are also evicted lazily, on
+// miss, in ServeHTTP.
+func (c *cachingHandler) sweepExpired() {
+	interval := c.ttl * 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			for key, entry := range c.entries {
+				if now.After(entry.expires) {
+					delete(c.entries, key)
+				}
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// Close stops the background sweep goroutine.
+func (c *cachingHandler) Close() {
+	close(c.stop)
+}
+
+func cacheKey(r *http.Request) string {
+	values := r.URL.Query()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	key.WriteString(r.Method)
+	key.WriteByte('|')
+	key.WriteString(r.Header.Get("Accept"))
+	for _, name := range names {
+		sort.Strings(values[name])
+		key.WriteByte('|')
+		key.WriteString(name)
+		key.WriteByte('=')
+		key.WriteString(strings.Join(values[name], ","))
+	}
+	return key.String()
+}
+
+func (c *cachingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := cacheKey(r)
+
+	c.mu.Lock()
+	entry, found := c.entries[key]
+	if found && time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		found = false
+	}
+	c.mu.Unlock()
+	if found {
+		writeCacheEntry(w, entry)
+		return
+	}
+
+	result, err, _ := c.group.Do(key, func() (interface{}, error) {
+		rec := newResponseRecorder()
+		c.next.ServeHTTP(rec, r)
+
+		entry := &cacheEntry{
+			header:  rec.header,
+			status:  rec.status,
+			body:    rec.body.Bytes(),
+			expires: time.Now().Add(c.ttl),
+		}
+		c.mu.Lock()
+		c.entries[key] = entry
+		c.mu.Unlock()
+		return entry, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeCacheEntry(w, result.(*cacheEntry))
+}
+
+func writeCacheEntry(w http.ResponseWriter, entry *cacheEntry) {
+	for name, values := range entry.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
+
+// cacheTTLFromEnv parses CACHE_TTL (a duration string like "500ms"),
+// defaulting to 500ms.
+func cacheTTLFromEnv() time.Duration {
+	if raw := os.Getenv("CACHE_TTL"); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil {
+			return d
+		}
+	}
+	return 500 * time.Millisecond
+}
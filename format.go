@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// filterProcs narrows procs down to those matching the query parameters:
+// pid=, ppid= (exact match), name= (substring), user= (exact match), and
+// env.FOO=bar (matches an environ entry). Unrecognized parameters are
+// ignored so format=/indent= can be passed alongside filters.
+func filterProcs(procs []*ProcInfo, query url.Values) ([]*ProcInfo, error) {
+	var wantPid, wantPpid int
+	var hasPid, hasPpid bool
+	var wantName, wantUser string
+
+	if v := query.Get("pid"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("malformed pid filter %q: %v", v, err)
+		}
+		wantPid, hasPid = n, true
+	}
+	if v := query.Get("ppid"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("malformed ppid filter %q: %v", v, err)
+		}
+		wantPpid, hasPpid = n, true
+	}
+	wantName = query.Get("name")
+	wantUser = query.Get("user")
+
+	wantEnv := make(map[string]string)
+	for key, values := range query {
+		if !strings.HasPrefix(key, "env.") {
+			continue
+		}
+		name := strings.TrimPrefix(key, "env.")
+		if name == "" {
+			return nil, fmt.Errorf("malformed env filter %q: missing variable name", key)
+		}
+		wantEnv[name] = values[0]
+	}
+
+	filtered := make([]*ProcInfo, 0, len(procs))
+	for _, proc := range procs {
+		if hasPid && proc.Pid != wantPid {
+			continue
+		}
+		if hasPpid && proc.Ppid != wantPpid {
+			continue
+		}
+		if wantName != "" && !strings.Contains(proc.Name, wantName) {
+			continue
+		}
+		if wantUser != "" && proc.User != wantUser {
+			continue
+		}
+		envMatches := true
+		for name, value := range wantEnv {
+			if proc.Environ[name] != value {
+				envMatches = false
+				break
+			}
+		}
+		if !envMatches {
+			continue
+		}
+		filtered = append(filtered, proc)
+	}
+	return filtered, nil
+}
+
+type procList struct {
+	XMLName xml.Name    `xml:"procs"`
+	Procs   []*ProcInfo `xml:"proc"`
+}
+
+// writeProcs encodes procs per the request's format=/Accept negotiation and
+// writes the response, or a 406 if neither names a format we support.
+func writeProcs(w http.ResponseWriter, r *http.Request, procs []*ProcInfo) {
+	indent := r.URL.Query().Get("indent") == "1"
+
+	format, ok := negotiateFormat(r)
+	if !ok {
+		http.Error(w, fmt.Sprintf("not acceptable: unsupported format/accept %q/%q", r.URL.Query().Get("format"), r.Header.Get("Accept")), http.StatusNotAcceptable)
+		return
+	}
+
+	var body []byte
+	var err error
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if indent {
+			body, err = json.MarshalIndent(procs, "", "  ")
+		} else {
+			body, err = json.Marshal(procs)
+		}
+	case "xml":
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		if indent {
+			body, err = xml.MarshalIndent(procList{Procs: procs}, "", "  ")
+		} else {
+			body, err = xml.Marshal(procList{Procs: procs})
+		}
+	case "yaml":
+		w.Header().Set("Content-Type", "application/x-yaml; charset=utf-8")
+		body, err = yaml.Marshal(procs)
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		body, err = renderProcsTable(procs, indent)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+var acceptToFormat = map[string]string{
+	"application/json":   "json",
+	"application/xml":    "xml",
+	"text/xml":           "xml",
+	"application/x-yaml": "yaml",
+	"application/yaml":   "yaml",
+	"text/yaml":          "yaml",
+	"text/plain":         "text",
+	"*/*":                "json",
+}
+
+// negotiateFormat picks a response format from the format= query param if
+// present, falling back to the Accept header, and finally defaulting to
+// JSON when the client doesn't express a preference at all.
+func negotiateFormat(r *http.Request) (string, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case "json", "xml", "yaml", "text":
+			return format, true
+		default:
+			return "", false
+		}
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return "json", true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if format, ok := acceptToFormat[mediaType]; ok {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+func renderProcsTable(procs []*ProcInfo, indent bool) ([]byte, error) {
+	var buf strings.Builder
+	minWidth := 0
+	if indent {
+		minWidth = 2
+	}
+	tw := tabwriter.NewWriter(&buf, minWidth, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PID\tPPID\tUSER\tSTATE\tNAME\tCMDLINE")
+	for _, proc := range procs {
+		cmdline := "-"
+		if len(proc.Cmdline) > 0 {
+			cmdline = string(proc.Cmdline)
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\n", proc.Pid, proc.Ppid, proc.User, proc.State, proc.Name, cmdline)
+	}
+	if err := tw.Flush(); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// readiness flips to not-ready as soon as shutdown begins, so a Kubernetes
+// readiness probe stops sending new traffic while /healthz still reports
+// the process as alive until Shutdown actually returns.
+type readiness struct {
+	ready int32
+}
+
+func newReadiness() *readiness {
+	r := &readiness{}
+	atomic.StoreInt32(&r.ready, 1)
+	return r
+}
+
+func (r *readiness) SetNotReady() { atomic.StoreInt32(&r.ready, 0) }
+
+func (r *readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if atomic.LoadInt32(&r.ready) == 0 {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	procsTotalDesc     = prometheus.NewDesc("procs_total", "Number of processes currently visible.", nil, nil)
+	procsByStateDesc   = prometheus.NewDesc("procs_by_state", "Number of processes in each state.", []string{"state"}, nil)
+	procCPUSecondsDesc = prometheus.NewDesc("proc_cpu_seconds_total", "Total user+system CPU time consumed by a process, in seconds.", []string{"pid", "name"}, nil)
+	procRSSDesc        = prometheus.NewDesc("proc_resident_memory_bytes", "Resident memory of a process, in bytes.", []string{"pid", "name"}, nil)
+	procOpenFDsDesc    = prometheus.NewDesc("proc_open_fds", "Number of open file descriptors held by a process.", []string{"pid", "name"}, nil)
+	procStartTimeDesc  = prometheus.NewDesc("proc_start_time_seconds", "Start time of a process since the unix epoch.", []string{"pid", "name"}, nil)
+)
+
+// fdCounter is implemented by ProcSource backends that can report open file
+// descriptor counts. Only procfs can today.
+type fdCounter interface {
+	OpenFDs(pid int) (int, error)
+}
+
+// procCollector implements prometheus.Collector over a ProcSource, scraped
+// fresh on every Collect rather than cached, since process state changes
+// faster than any sane scrape interval.
+type procCollector struct {
+	source  ProcSource
+	manager *ProcessManager
+	match   *regexp.Regexp
+}
+
+func (c *procCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- procsTotalDesc
+	ch <- procsByStateDesc
+	ch <- procCPUSecondsDesc
+	ch <- procRSSDesc
+	ch <- procOpenFDsDesc
+	ch <- procStartTimeDesc
+}
+
+func (c *procCollector) Collect(ch chan<- prometheus.Metric) {
+	procs, err := readAllProcs(c.source, c.manager)
+	if err != nil {
+		return
+	}
+
+	fds, hasFDs := c.source.(fdCounter)
+	byState := make(map[string]int)
+	for _, proc := range procs {
+		byState[proc.State]++
+
+		// Per-pid labeled series are unbounded cardinality on a busy host,
+		// so only emit them for names the caller opted into via ?match=.
+		if c.match == nil || !c.match.MatchString(proc.Name) {
+			continue
+		}
+		pid := strconv.Itoa(proc.Pid)
+		ch <- prometheus.MustNewConstMetric(procCPUSecondsDesc, prometheus.CounterValue, (proc.UserCPU + proc.SysCPU).Seconds(), pid, proc.Name)
+		ch <- prometheus.MustNewConstMetric(procRSSDesc, prometheus.GaugeValue, float64(proc.RSS), pid, proc.Name)
+		ch <- prometheus.MustNewConstMetric(procStartTimeDesc, prometheus.GaugeValue, float64(proc.StartTime.Unix()), pid, proc.Name)
+		if hasFDs {
+			if n, err := fds.OpenFDs(proc.Pid); err == nil {
+				ch <- prometheus.MustNewConstMetric(procOpenFDsDesc, prometheus.GaugeValue, float64(n), pid, proc.Name)
+			}
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(procsTotalDesc, prometheus.GaugeValue, float64(len(procs)))
+	for state, count := range byState {
+		ch <- prometheus.MustNewConstMetric(procsByStateDesc, prometheus.GaugeValue, float64(count), state)
+	}
+}
+
+type metricsHandler struct {
+	source  ProcSource
+	manager *ProcessManager
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("match")
+	if pattern == "" {
+		pattern = os.Getenv("METRICS_MATCH")
+	}
+
+	var match *regexp.Regexp
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("malformed match regex: %v", err), http.StatusBadRequest)
+			return
+		}
+		match = re
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&procCollector{h.source, h.manager, match})
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ringBuffer is a fixed-size byte buffer that keeps only the most recently
+// written bytes, so long-running children don't grow stdio capture without
+// bound.
+type ringBuffer struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	max  int
+	subs map[chan []byte]struct{}
+}
+
+func newRingBuffer(max int) *ringBuffer {
+	return &ringBuffer{max: max, subs: make(map[chan []byte]struct{})}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	r.buf.Write(p)
+	if over := r.buf.Len() - r.max; over > 0 {
+		r.buf.Next(over)
+	}
+	for ch := range r.subs {
+		select {
+		case ch <- append([]byte(nil), p...):
+		default:
+			// drop slow consumer's chunk rather than block the writer
+		}
+	}
+	r.mu.Unlock()
+	return len(p), nil
+}
+
+func (r *ringBuffer) Bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]byte(nil), r.buf.Bytes()...)
+}
+
+func (r *ringBuffer) Subscribe() chan []byte {
+	ch := make(chan []byte, 16)
+	r.mu.Lock()
+	r.subs[ch] = struct{}{}
+	r.mu.Unlock()
+	return ch
+}
+
+func (r *ringBuffer) Unsubscribe(ch chan []byte) {
+	r.mu.Lock()
+	delete(r.subs, ch)
+	r.mu.Unlock()
+}
+
+func (r *ringBuffer) Close() {
+	r.mu.Lock()
+	for ch := range r.subs {
+		close(ch)
+		delete(r.subs, ch)
+	}
+	r.mu.Unlock()
+}
+
+type managedProc struct {
+	Pid       int
+	StartedAt time.Time
+	cmd       *exec.Cmd
+	stdout    *ringBuffer
+	stderr    *ringBuffer
+	done      chan struct{}
+	exitCode  int
+	signal    string
+	rusage    *syscall.Rusage
+}
+
+// ProcessManager spawns and supervises child processes started through the
+// API, as opposed to processes merely observed via ProcSource.
+type ProcessManager struct {
+	mu    sync.Mutex
+	procs map[int]*managedProc
+}
+
+func NewProcessManager() *ProcessManager {
+	return &ProcessManager{procs: make(map[int]*managedProc)}
+}
+
+// Owns reports whether pid was started by this manager, for tagging
+// ProcInfo.Owned in readAllProcs results.
+func (m *ProcessManager) Owns(pid int) bool {
+	m.mu.Lock()
+	_, ok := m.procs[pid]
+	m.mu.Unlock()
+	return ok
+}
+
+type spawnRequest struct {
+	Path string            `json:"path"`
+	Argv []string          `json:"argv"`
+	Env  map[string]string `json:"env"`
+	Cwd  string            `json:"cwd"`
+}
+
+func (m *ProcessManager) Spawn(req spawnRequest) (*managedProc, error) {
+	cmd := exec.Command(req.Path, req.Argv...)
+	cmd.Dir = req.Cwd
+	for name, value := range req.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", name, value))
+	}
+
+	mp := &managedProc{
+		cmd:    cmd,
+		stdout: newRingBuffer(64 * 1024),
+		stderr: newRingBuffer(64 * 1024),
+		done:   make(chan struct{}),
+	}
+	cmd.Stdout = mp.stdout
+	cmd.Stderr = mp.stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	mp.Pid = cmd.Process.Pid
+	mp.StartedAt = time.Now()
+
+	m.mu.Lock()
+	m.procs[mp.Pid] = mp
+	m.mu.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+		mp.exitCode = -1
+		if state := cmd.ProcessState; state != nil {
+			mp.exitCode = state.ExitCode()
+			if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+				mp.rusage = rusage
+			}
+			if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+				mp.signal = ws.Signal().String()
+			}
+		}
+		_ = err
+		close(mp.done)
+		mp.stdout.Close()
+		mp.stderr.Close()
+	}()
+
+	return mp, nil
+}
+
+func (m *ProcessManager) get(pid int) (*managedProc, bool) {
+	m.mu.Lock()
+	mp, ok := m.procs[pid]
+	m.mu.Unlock()
+	return mp, ok
+}
+
+var signalsByName = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGCONT": syscall.SIGCONT,
+	"SIGSTOP": syscall.SIGSTOP,
+}
+
+func (m *ProcessManager) Signal(pid int, name string) error {
+	mp, ok := m.get(pid)
+	if !ok {
+		return fmt.Errorf("no managed process with pid %d", pid)
+	}
+	select {
+	case <-mp.done:
+		// the pid may since have been recycled by the OS for an unrelated
+		// process; refuse to signal it rather than risk hitting that.
+		return fmt.Errorf("process %d has already exited", pid)
+	default:
+	}
+
+	sig, ok := signalsByName[strings.ToUpper(name)]
+	if !ok {
+		return fmt.Errorf("unknown signal %q", name)
+	}
+	return syscall.Kill(mp.Pid, sig)
+}
+
+type processHandler struct {
+	manager *ProcessManager
+}
+
+func (h *processHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the "/procs/" prefix is already stripped by the caller, so parts[0]
+	// is the pid (or empty, for the bare spawn endpoint).
+	trimmed := strings.Trim(r.URL.Path, "/")
+	if trimmed == "" {
+		h.handleSpawn(w, r)
+		return
+	}
+	parts := strings.Split(trimmed, "/")
+
+	pid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid pid %q", parts[0]), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		h.handleSignal(w, pid, "SIGTERM")
+	case len(parts) == 2 && parts[1] == "signal":
+		h.handleSignalRequest(w, r, pid)
+	case len(parts) == 2 && parts[1] == "stdout":
+		h.handleStream(w, r, pid, true)
+	case len(parts) == 2 && parts[1] == "stderr":
+		h.handleStream(w, r, pid, false)
+	case len(parts) == 2 && parts[1] == "exit":
+		h.handleExit(w, pid)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *processHandler) handleSpawn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req spawnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("malformed request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	mp, err := h.manager.Spawn(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to spawn process: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Pid       int       `json:"pid"`
+		StartedAt time.Time `json:"startedAt"`
+	}{mp.Pid, mp.StartedAt})
+}
+
+type signalRequest struct {
+	Signal string `json:"signal"`
+}
+
+func (h *processHandler) handleSignalRequest(w http.ResponseWriter, r *http.Request, pid int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req signalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("malformed request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	h.handleSignal(w, pid, req.Signal)
+}
+
+func (h *processHandler) handleSignal(w http.ResponseWriter, pid int, signal string) {
+	if err := h.manager.Signal(pid, signal); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *processHandler) handleStream(w http.ResponseWriter, r *http.Request, pid int, stdout bool) {
+	mp, ok := h.manager.get(pid)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	rb := mp.stderr
+	if stdout {
+		rb = mp.stdout
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	flusher, canFlush := w.(http.Flusher)
+
+	w.Write(rb.Bytes())
+	if canFlush {
+		flusher.Flush()
+	}
+
+	if r.URL.Query().Get("follow") != "1" {
+		return
+	}
+
+	sub := rb.Subscribe()
+	defer rb.Unsubscribe(sub)
+	for {
+		select {
+		case chunk, open := <-sub:
+			if !open {
+				// headers are already sent by the time the exit code is
+				// known, so declare the trailer dynamically via the
+				// TrailerPrefix mechanism rather than the Trailer header.
+				<-mp.done
+				w.Header().Set(http.TrailerPrefix+"X-Exit-Code", strconv.Itoa(mp.exitCode))
+				return
+			}
+			w.Write(chunk)
+			if canFlush {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (h *processHandler) handleExit(w http.ResponseWriter, pid int) {
+	mp, ok := h.manager.get(pid)
+	if !ok {
+		http.NotFound(w, nil)
+		return
+	}
+	<-mp.done
+
+	var rusage interface{}
+	if mp.rusage != nil {
+		rusage = mp.rusage
+	}
+	writeJSON(w, http.StatusOK, struct {
+		ExitCode int         `json:"exitCode"`
+		Signal   string      `json:"signal,omitempty"`
+		Rusage   interface{} `json:"rusage,omitempty"`
+	}{mp.exitCode, mp.signal, rusage})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,14 @@
+package main
+
+// ProcSource abstracts where process information comes from, so the rest of
+// the program doesn't need to know whether it's talking to /proc or some
+// other platform's equivalent.
+type ProcSource interface {
+	// List returns the pids currently visible to this source.
+	List() ([]int, error)
+	// Read returns info for a single pid. Implementations should return an
+	// os.IsNotExist-compatible error if the process disappeared before it
+	// could be read, rather than panicking - processes come and go
+	// constantly during a scan.
+	Read(pid int) (*ProcInfo, error)
+}
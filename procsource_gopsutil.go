@@ -0,0 +1,78 @@
+package main
+
+import (
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// gopsutilProcSource backs ProcSource with gopsutil, so macOS, FreeBSD and
+// Windows get the same API as the native /proc implementation, with
+// whichever fields their platform actually exposes.
+type gopsutilProcSource struct{}
+
+func newGopsutilProcSource() *gopsutilProcSource {
+	return &gopsutilProcSource{}
+}
+
+func (s *gopsutilProcSource) List() ([]int, error) {
+	pids, err := process.Pids()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(pids))
+	for i, pid := range pids {
+		ids[i] = int(pid)
+	}
+	return ids, nil
+}
+
+func (s *gopsutilProcSource) Read(pid int) (*ProcInfo, error) {
+	proc, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := proc.Name()
+	ppid32, _ := proc.Ppid()
+	cmdline, _ := proc.Cmdline()
+	environSlice, _ := proc.Environ()
+	state, _ := proc.Status()
+	numThreads32, _ := proc.NumThreads()
+	startTimeMs, _ := proc.CreateTime()
+	times, _ := proc.Times()
+	mem, _ := proc.MemoryInfo()
+	username, _ := proc.Username()
+
+	environ := make(map[string]string, len(environSlice))
+	for _, entry := range environSlice {
+		for i := 0; i < len(entry); i++ {
+			if entry[i] == '=' {
+				environ[entry[:i]] = entry[i+1:]
+				break
+			}
+		}
+	}
+
+	info := &ProcInfo{
+		Pid:        pid,
+		Ppid:       int(ppid32),
+		Name:       name,
+		Cmdline:    cmdlineOrFalse(cmdline),
+		Environ:    environ,
+		User:       username,
+		NumThreads: int(numThreads32),
+		StartTime:  time.UnixMilli(startTimeMs),
+	}
+	if len(state) > 0 {
+		info.State = state[0]
+	}
+	if mem != nil {
+		info.RSS = mem.RSS
+	}
+	if times != nil {
+		info.UserCPU = time.Duration(times.User * float64(time.Second))
+		info.SysCPU = time.Duration(times.System * float64(time.Second))
+	}
+	return info, nil
+}
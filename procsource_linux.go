@@ -0,0 +1,264 @@
+//go:build linux
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicksPerSecond is the kernel's USER_HZ, used to convert the utime and
+// stime fields of /proc/{pid}/stat into durations. It's 100 on effectively
+// every Linux platform Go supports; reading the real value out of the
+// vsyscall vDSO isn't worth the complexity here.
+const clockTicksPerSecond = 100
+
+// procfsSource reads process information straight out of /proc.
+type procfsSource struct {
+	procPath string
+	pageSize int64
+
+	usernamesMu sync.Mutex
+	usernames   map[string]string
+}
+
+func newProcfsSource() *procfsSource {
+	return &procfsSource{
+		procPath:  "/proc",
+		pageSize:  int64(os.Getpagesize()),
+		usernames: make(map[string]string),
+	}
+}
+
+// username resolves a uid to a name, caching lookups since os/user hits the
+// filesystem and a busy host has many processes sharing a handful of uids.
+func (s *procfsSource) username(uid string) string {
+	s.usernamesMu.Lock()
+	defer s.usernamesMu.Unlock()
+	if name, ok := s.usernames[uid]; ok {
+		return name
+	}
+	name := uid
+	if u, err := user.LookupId(uid); err == nil {
+		name = u.Username
+	}
+	s.usernames[uid] = name
+	return name
+}
+
+func (s *procfsSource) readUser(pid int) (string, error) {
+	statusFile := path.Join(s.procPath, strconv.Itoa(pid), "status")
+	statusBytes, err := ioutil.ReadFile(statusFile)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(statusBytes), "\n") {
+		if !strings.HasPrefix(line, "Uid:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			break
+		}
+		return s.username(fields[1]), nil
+	}
+	return "", nil
+}
+
+func (s *procfsSource) List() ([]int, error) {
+	entries, err := ioutil.ReadDir(s.procPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pids := make([]int, 0, len(entries))
+	for _, f := range entries {
+		if pid, err := strconv.Atoi(f.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func (s *procfsSource) readCmdline(pid int) (string, error) {
+	cmdFile := path.Join(s.procPath, strconv.Itoa(pid), "cmdline")
+	cmdlineBytes, err := ioutil.ReadFile(cmdFile)
+	if err != nil {
+		return "", err
+	}
+	cmdline := strings.Replace(string(cmdlineBytes), "\000", " ", -1)
+	if len(cmdline) > 0 {
+		cmdline = cmdline[:len(cmdline)-1]
+	}
+	return cmdline, nil
+}
+
+func (s *procfsSource) readEnviron(pid int) (map[string]string, error) {
+	environFile := path.Join(s.procPath, strconv.Itoa(pid), "environ")
+	environBytes, err := ioutil.ReadFile(environFile)
+	if err != nil {
+		if os.IsNotExist(err) || os.IsPermission(err) {
+			// environ of another user's process is commonly unreadable
+			// (EACCES) on a multi-user host; that's expected, not fatal.
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	environ := make(map[string]string)
+	for _, environEntry := range strings.Split(string(environBytes), "\000") {
+		entryParts := strings.Split(environEntry, "=")
+		name := entryParts[0]
+		if len(name) == 0 {
+			continue
+		}
+		var value string
+		if len(entryParts) > 1 {
+			value = entryParts[1]
+		}
+		environ[name] = value
+	}
+	return environ, nil
+}
+
+func (s *procfsSource) readRSS(pid int) (uint64, error) {
+	statusFile := path.Join(s.procPath, strconv.Itoa(pid), "status")
+	statusBytes, err := ioutil.ReadFile(statusFile)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(statusBytes), "\n") {
+		if !strings.HasPrefix(line, "VmRSS:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			break
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, nil
+		}
+		return kb * 1024, nil
+	}
+	return 0, nil
+}
+
+// OpenFDs counts the entries in /proc/{pid}/fd, giving the number of file
+// descriptors the process currently holds open.
+func (s *procfsSource) OpenFDs(pid int) (int, error) {
+	fdDir := path.Join(s.procPath, strconv.Itoa(pid), "fd")
+	entries, err := ioutil.ReadDir(fdDir)
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+func (s *procfsSource) Read(pid int) (*ProcInfo, error) {
+	statFile := path.Join(s.procPath, strconv.Itoa(pid), "stat")
+	statBytes, err := ioutil.ReadFile(statFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// comm can itself contain spaces, so split on the parens around it
+	// rather than assuming a fixed field count before it.
+	statLine := string(statBytes)
+	openParen := strings.IndexByte(statLine, '(')
+	closeParen := strings.LastIndexByte(statLine, ')')
+	if openParen < 0 || closeParen < openParen {
+		return nil, os.ErrNotExist
+	}
+	name := statLine[openParen+1 : closeParen]
+	rest := strings.Fields(statLine[closeParen+1:])
+	// rest[0] is field 3 (state); field N in the man page is rest[N-3].
+	field := func(n int) string {
+		i := n - 3
+		if i < 0 || i >= len(rest) {
+			return ""
+		}
+		return rest[i]
+	}
+
+	ppid, err := strconv.Atoi(field(4))
+	if err != nil {
+		return nil, err
+	}
+	numThreads, _ := strconv.Atoi(field(20))
+	utimeTicks, _ := strconv.ParseInt(field(14), 10, 64)
+	stimeTicks, _ := strconv.ParseInt(field(15), 10, 64)
+	startTicks, _ := strconv.ParseInt(field(22), 10, 64)
+
+	cmdline, err := s.readCmdline(pid)
+	if err != nil {
+		return nil, err
+	}
+	environ, err := s.readEnviron(pid)
+	if err != nil {
+		return nil, err
+	}
+	rss, err := s.readRSS(pid)
+	if err != nil {
+		return nil, err
+	}
+	username, err := s.readUser(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProcInfo{
+		Pid:        pid,
+		Ppid:       ppid,
+		Name:       name,
+		Cmdline:    cmdlineOrFalse(cmdline),
+		Environ:    environ,
+		User:       username,
+		State:      field(3),
+		NumThreads: numThreads,
+		RSS:        rss,
+		UserCPU:    time.Duration(utimeTicks) * time.Second / clockTicksPerSecond,
+		SysCPU:     time.Duration(stimeTicks) * time.Second / clockTicksPerSecond,
+		StartTime:  bootTime().Add(time.Duration(startTicks) * time.Second / clockTicksPerSecond),
+	}, nil
+}
+
+// bootTime returns the kernel's boot time, against which process start
+// ticks (field 22) are relative.
+func bootTime() time.Time {
+	statBytes, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}
+	}
+	for _, line := range strings.Split(string(statBytes), "\n") {
+		if !strings.HasPrefix(line, "btime ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			break
+		}
+		secs, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			break
+		}
+		return time.Unix(secs, 0)
+	}
+	return time.Time{}
+}
+
+// NewProcSource picks the process source for this platform, honoring
+// PROCSOURCE=gopsutil to force the portable backend even where /proc is
+// available (e.g. to sanity-check it against the native one).
+func NewProcSource() ProcSource {
+	if os.Getenv("PROCSOURCE") == "gopsutil" {
+		return newGopsutilProcSource()
+	}
+	return newProcfsSource()
+}
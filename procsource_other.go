@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// NewProcSource picks the process source for this platform. There's no
+// native /proc here, so gopsutil is the only option regardless of
+// PROCSOURCE.
+func NewProcSource() ProcSource {
+	if name := os.Getenv("PROCSOURCE"); name != "" && name != "gopsutil" {
+		panic("PROCSOURCE=" + name + " is not available on this platform, only gopsutil")
+	}
+	return newGopsutilProcSource()
+}
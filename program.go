@@ -3,14 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
-	"path"
-	"strconv"
-	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 type cmdlineOrFalse string
@@ -24,115 +25,67 @@ func (cmdline cmdlineOrFalse) MarshalJSON() ([]byte, error) {
 }
 
 type ProcInfo struct {
-	Pid     int
-	Ppid    int
-	Name    string
-	Cmdline cmdlineOrFalse
-	Environ map[string]string
+	Pid        int
+	Ppid       int
+	Name       string
+	Cmdline    cmdlineOrFalse
+	Environ    map[string]string
+	User       string
+	Owned      bool
+	StartTime  time.Time
+	State      string
+	NumThreads int
+	RSS        uint64
+	UserCPU    time.Duration
+	SysCPU     time.Duration
 }
 
-const procPath = "/proc"
-
-func readDir(dir string) ([]os.FileInfo, error) {
-	files, err := ioutil.ReadDir(dir)
+func readAllProcs(source ProcSource, manager *ProcessManager) ([]*ProcInfo, error) {
+	pids, err := source.List()
 	if err != nil {
 		return nil, err
 	}
 
-	return files, nil
-}
-
-func readCmdline(pid int) string {
-	cmdFile := path.Join(procPath, strconv.Itoa(pid), "cmdline")
-	cmdlineBytes, err := ioutil.ReadFile(cmdFile)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to read cmdline file: %v", err))
-	}
-	cmdline := strings.Replace(string(cmdlineBytes), "\000", " ", -1)
-	if len(cmdline) > 0 {
-		cmdline = cmdline[:len(cmdline)-1]
-	}
-	return cmdline
-}
-
-func readEnviron(pid int) map[string]string {
-	environFile := path.Join(procPath, strconv.Itoa(pid), "environ")
-	var environ map[string]string
-	if environBytes, err := ioutil.ReadFile(environFile); err == nil {
-		environEntries := strings.Split(string(environBytes), "\000")
-		environ = make(map[string]string)
-		for _, environEntry := range environEntries {
-			entryParts := strings.Split(environEntry, "=")
-			name := entryParts[0]
-			if len(name) == 0 {
+	ownPid := os.Getpid()
+	procs := make([]*ProcInfo, 0, len(pids))
+	for _, pid := range pids {
+		if pid == ownPid {
+			continue
+		}
+		proc, err := source.Read(pid)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// process exited between List and Read; skip it rather than
+				// fail the whole scan
 				continue
 			}
-			var value string
-			if len(entryParts) > 1 {
-				value = entryParts[1]
-			}
-			environ[name] = value
+			return nil, err
 		}
+		proc.Owned = manager.Owns(pid)
+		procs = append(procs, proc)
 	}
-
-	return environ
+	return procs, nil
 }
 
-func readProc(pid int) *ProcInfo {
-	statFile := path.Join(procPath, strconv.Itoa(pid), "stat")
-	statBytes, err := ioutil.ReadFile(statFile)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to read stat file: %v", err))
-	}
-	stat := strings.Split(string(statBytes), " ")
-	name := stat[1]
-	if len(name) > 0 {
-		name = name[1 : len(name)-1]
-	}
-	ppid, err := strconv.Atoi(stat[3])
-	if err != nil {
-		panic(fmt.Sprintf("Failed to parse ppid as int: %v", err))
-	}
-	cmdline := readCmdline(pid)
-	environ := readEnviron(pid)
-
-	return &ProcInfo{pid, ppid, name, cmdlineOrFalse(cmdline), environ}
+type serverHandler struct {
+	source  ProcSource
+	manager *ProcessManager
 }
 
-func readAllProcs() []*ProcInfo {
-	entries, err := readDir(procPath)
+func (s *serverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	procs, err := readAllProcs(s.source, s.manager)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to read procfs: %v", err))
-	}
-
-	ownPid := os.Getpid()
-	procs := make([]*ProcInfo, 0)
-	for _, f := range entries {
-		pid, err := strconv.Atoi(f.Name())
-		if err != nil || pid == ownPid {
-			continue
-		}
-		procs = append(procs, readProc(pid))
+		http.Error(w, fmt.Sprintf("Failed to read procs: %v", err), http.StatusInternalServerError)
+		return
 	}
-	return procs
-}
-
-type serverHandler struct{}
 
-func (s *serverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var status = http.StatusOK
-	var body []byte
-	if jsonBytes, err := json.Marshal(readAllProcs()); err == nil {
-		body = jsonBytes
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	} else {
-		status = http.StatusInternalServerError
-		body = []byte(fmt.Sprintf("Failed to marshall: %v", err))
+	procs, err = filterProcs(procs, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	w.Header().Set("Content-Length", fmt.Sprintf("%v", len(body)))
-	w.WriteHeader(status)
-	w.Write(body)
+	writeProcs(w, r, procs)
 }
 
 func main() {
@@ -140,17 +93,48 @@ func main() {
 	if addr == ":" {
 		addr = ":8888"
 	}
-	httpServer := &http.Server{Addr: addr, Handler: &serverHandler{}}
+	source := NewProcSource()
+	manager := NewProcessManager()
+	broker := newEventBroker()
+	ready := newReadiness()
+
+	stopPoller := make(chan struct{})
+	go pollProcs(source, manager, broker, pollIntervalFromEnv(), stopPoller)
+
+	cache := newCachingHandler(&serverHandler{source, manager}, cacheTTLFromEnv())
+
+	procs := &processHandler{manager}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", cache)
+	mux.Handle("/procs/stream", &streamHandler{broker})
+	mux.Handle("/procs/tree", &treeHandler{source, manager})
+	mux.Handle("/metrics", &metricsHandler{source, manager})
+	mux.HandleFunc("/procs", procs.handleSpawn)
+	mux.Handle("/procs/", http.StripPrefix("/procs/", procs))
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.Handle("/readyz", ready)
+	httpServer := &http.Server{Addr: addr, Handler: mux}
 
 	go func() {
 		fmt.Printf("Listening on http://0.0.0.0%s\n", addr)
-		if err := httpServer.ListenAndServe(); err != nil {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			panic(err)
 		}
 	}()
 
 	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
 	<-ch
-	httpServer.Shutdown(context.Background())
+	ready.SetNotReady()
+
+	var eg errgroup.Group
+	eg.Go(func() error { close(stopPoller); return nil })
+	eg.Go(func() error { broker.CloseAll(); return nil })
+	eg.Go(func() error { cache.Close(); return nil })
+	eg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	httpServer.Shutdown(ctx)
 }
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event is a single process-tree change, fanned out to SSE subscribers.
+type Event struct {
+	Type string    `json:"type"`
+	Proc *ProcInfo `json:"proc"`
+}
+
+// eventBroker fans Events out to subscribers. Slow consumers have events
+// dropped rather than block the poller that feeds the broker.
+type eventBroker struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subs: make(map[chan Event]struct{})}
+}
+
+func (b *eventBroker) Subscribe() chan Event {
+	ch := make(chan Event, 64)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *eventBroker) Unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	delete(b.subs, ch)
+	b.mu.Unlock()
+}
+
+// CloseAll closes every subscriber channel, so SSE handlers blocked reading
+// from them can return during shutdown.
+func (b *eventBroker) CloseAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		close(ch)
+		delete(b.subs, ch)
+	}
+}
+
+func (b *eventBroker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			// drop the event for this slow subscriber rather than block
+			// the poller for everyone else
+		}
+	}
+}
+
+// procKey disambiguates a pid from a previous process that held the same
+// pid, using the start time recorded in field 22 of /proc/{pid}/stat (or
+// the equivalent gopsutil field).
+type procKey struct {
+	pid       int
+	startTime int64
+}
+
+func keyOf(proc *ProcInfo) procKey {
+	return procKey{pid: proc.Pid, startTime: proc.StartTime.UnixNano()}
+}
+
+// pollProcs polls source on interval, diffs consecutive snapshots by
+// procKey, and publishes spawn/exit/update events to broker until stop is
+// closed.
+func pollProcs(source ProcSource, manager *ProcessManager, broker *eventBroker, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev := make(map[procKey]*ProcInfo)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		procs, err := readAllProcs(source, manager)
+		if err != nil {
+			continue
+		}
+
+		current := make(map[procKey]*ProcInfo, len(procs))
+		for _, proc := range procs {
+			current[keyOf(proc)] = proc
+		}
+
+		for key, proc := range current {
+			if prevProc, ok := prev[key]; !ok {
+				broker.Publish(Event{Type: "spawn", Proc: proc})
+			} else if prevProc.State != proc.State || prevProc.RSS != proc.RSS {
+				broker.Publish(Event{Type: "update", Proc: proc})
+			}
+		}
+		for key, proc := range prev {
+			if _, ok := current[key]; !ok {
+				broker.Publish(Event{Type: "exit", Proc: proc})
+			}
+		}
+
+		prev = current
+	}
+}
+
+type streamHandler struct {
+	broker *eventBroker
+}
+
+func (h *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	sub := h.broker.Subscribe()
+	defer h.broker.Unsubscribe(sub)
+
+	for {
+		select {
+		case event, open := <-sub:
+			if !open {
+				return
+			}
+			payload, err := json.Marshal(event.Proc)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, payload)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// procTreeNode nests a ProcInfo under its children, built from ppid links.
+type procTreeNode struct {
+	*ProcInfo
+	Children []*procTreeNode `json:"children,omitempty"`
+}
+
+func buildProcTree(procs []*ProcInfo) []*procTreeNode {
+	nodes := make(map[int]*procTreeNode, len(procs))
+	for _, proc := range procs {
+		nodes[proc.Pid] = &procTreeNode{ProcInfo: proc}
+	}
+
+	var roots []*procTreeNode
+	for _, node := range nodes {
+		if parent, ok := nodes[node.Ppid]; ok && parent != node {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Pid < roots[j].Pid })
+	for _, node := range nodes {
+		sort.Slice(node.Children, func(i, j int) bool { return node.Children[i].Pid < node.Children[j].Pid })
+	}
+	return roots
+}
+
+type treeHandler struct {
+	source  ProcSource
+	manager *ProcessManager
+}
+
+func (h *treeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	procs, err := readAllProcs(h.source, h.manager)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read procs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	body, err := json.Marshal(buildProcTree(procs))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(body)
+}
+
+// pollIntervalFromEnv parses POLL_INTERVAL (a duration string like "1s" or
+// "500ms"), defaulting to 1 second.
+func pollIntervalFromEnv() time.Duration {
+	if raw := os.Getenv("POLL_INTERVAL"); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil {
+			return d
+		}
+	}
+	return time.Second
+}